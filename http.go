@@ -0,0 +1,107 @@
+// HTTP server mode, exposing a MarkVShaney chain for training and
+// generation over a simple JSON-free text API. MarkVShaney is safe for
+// concurrent use, so training and generation can run against the chain
+// at the same time.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxTrainBodyBytes caps how much a single POST /train request body
+// can be, so an unauthenticated client can't exhaust memory by
+// streaming an unbounded body into the chain.
+const maxTrainBodyBytes = 10 << 20 // 10 MiB
+
+// maxGenerateParagraphs caps the paragraphs query param on GET
+// /generate, so a client can't ask for an unbounded number of
+// GetDocumentFrom walks in a single request.
+const maxGenerateParagraphs = 100
+
+// serveHTTP starts an HTTP server on addr exposing mvs for training and
+// generation. It blocks until the server exits, which it only does on
+// error.
+func serveHTTP(addr string, mvs *MarkVShaney, tok Tokenizer) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/train", handleTrain(mvs, tok))
+	mux.HandleFunc("/generate", handleGenerate(mvs))
+	mux.HandleFunc("/state", handleState(mvs))
+	return http.ListenAndServe(addr, mux)
+}
+
+// handleTrain returns a handler for POST /train, which feeds the
+// request body into mvs as a corpus, tokenized with tok.
+func handleTrain(mvs *MarkVShaney, tok Tokenizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := trainReader(http.MaxBytesReader(w, r.Body, maxTrainBodyBytes), mvs, tok); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleGenerate returns a handler for GET /generate?paragraphs=N&seed=...,
+// which writes N generated paragraphs to the response, one per line,
+// each optionally continuing on from seed.
+func handleGenerate(mvs *MarkVShaney) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "GET only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		paragraphs := 1
+		if n := r.URL.Query().Get("paragraphs"); n != "" {
+			v, err := strconv.Atoi(n)
+			if err != nil || v < 1 || v > maxGenerateParagraphs {
+				http.Error(w, fmt.Sprintf("paragraphs must be between 1 and %d", maxGenerateParagraphs), http.StatusBadRequest)
+				return
+			}
+			paragraphs = v
+		}
+		seed := strings.Fields(r.URL.Query().Get("seed"))
+		rng := rand.New(rand.NewSource(rand.Int63()))
+
+		for i := 0; i < paragraphs; i++ {
+			doc, err := mvs.GetDocumentFrom(seed, rng)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, strings.Join(doc, " "))
+		}
+	}
+}
+
+// handleState returns a handler for GET /state, which dumps mvs as a
+// gob stream, and POST /state, which replaces mvs with a gob stream
+// from the request body.
+func handleState(mvs *MarkVShaney) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			if err := mvs.Save(w); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		case http.MethodPost:
+			if err := mvs.Load(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGenerateDoesNotHangOnCyclicTraining trains a chain over /train
+// with a corpus that cycles forever (every prefix has exactly one
+// known successor, so the walk never resolves to Terminal) and checks
+// that /generate still responds instead of parking the handler
+// goroutine forever.
+func TestGenerateDoesNotHangOnCyclicTraining(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+
+	trainReq := httptest.NewRequest(http.MethodPost, "/train", strings.NewReader("A B A B"))
+	trainRec := httptest.NewRecorder()
+	handleTrain(mvs, wordTokenizer{})(trainRec, trainReq)
+	if trainRec.Code != http.StatusNoContent {
+		t.Fatalf("POST /train: status = %d, want %d", trainRec.Code, http.StatusNoContent)
+	}
+
+	done := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/generate", nil)
+		rec := httptest.NewRecorder()
+		handleGenerate(mvs)(rec, req)
+		done <- rec.Code
+	}()
+
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("GET /generate: status = %d, want %d", code, http.StatusOK)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("GET /generate did not return within 2s on cyclic training data")
+	}
+}
+
+func TestHandleGenerateRejectsExcessiveParagraphs(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+
+	req := httptest.NewRequest(http.MethodGet, "/generate?paragraphs=100000", nil)
+	rec := httptest.NewRecorder()
+	handleGenerate(mvs)(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /generate?paragraphs=100000: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleTrainRejectsOversizedBody(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+
+	// Many short paragraphs, so the body is rejected for crossing
+	// maxTrainBodyBytes rather than for one paragraph exceeding
+	// bufio.Scanner's token size limit.
+	body := strings.NewReader(strings.Repeat(strings.Repeat("A", 1000)+"\n\n", maxTrainBodyBytes/1000+1))
+	req := httptest.NewRequest(http.MethodPost, "/train", body)
+	rec := httptest.NewRecorder()
+	handleTrain(mvs, wordTokenizer{})(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /train with oversized body: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
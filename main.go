@@ -3,55 +3,164 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
+	"io"
+	"math/rand"
 	"os"
 	"strings"
 )
 
+var (
+	prefixLen = flag.Int("prefix", 2, "number of words in the Markov chain prefix")
+	stateFile = flag.String("state", "", "path to load and save persisted chain state")
+	trainFile = flag.String("train", "", "path to an additional corpus to train into the chain")
+	seedWords = flag.String("seed", "", "prompt to seed generation from, e.g. \"the quick brown\"")
+	randSeed  = flag.Int64("rand-seed", 0, "seed for the random number generator; 0 picks a random seed")
+	httpAddr  = flag.String("http", "", "address to serve a train/generate HTTP API on, e.g. :8080, instead of printing to stdout")
+	tokenize  = flag.String("tokenize", "words", "how to split input into tokens: words, unicode, or sentences")
+)
+
 func main() {
-	mvs := MarkVShaney{}
-	for _, filename := range os.Args[1:] {
-		if err := parseInput(filename, mvs); err != nil {
+	flag.Parse()
+
+	tok, err := tokenizerByName(*tokenize)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := validatePrefixLen(*prefixLen); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	mvs := NewMarkVShaney(*prefixLen)
+	if *stateFile != "" {
+		if err := loadState(*stateFile, mvs); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
 	}
+
+	filenames := flag.Args()
+	if *trainFile != "" {
+		filenames = append(filenames, *trainFile)
+	}
+	for _, filename := range filenames {
+		if err := parseInput(filename, mvs, tok); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if *stateFile != "" {
+		if err := saveState(*stateFile, mvs); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if *httpAddr != "" {
+		if err := serveHTTP(*httpAddr, mvs, tok); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	rng := rand.New(rand.NewSource(*randSeed))
+	if *randSeed == 0 {
+		rng = rand.New(rand.NewSource(rand.Int63()))
+	}
+	seed := strings.Fields(*seedWords)
+
 	// Output 5 paragraphs.
 	// TODO(camh-): Make number of paragraphs a CLI option
 	for i := 0; i < 5; i++ {
-		fmt.Println(strings.Join(mvs.GetDocument(), " "), "\n")
+		doc, err := mvs.GetDocumentFrom(seed, rng)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(strings.Join(doc, " "), "\n")
+	}
+}
+
+// validatePrefixLen checks that n is a usable MarkVShaney prefix
+// length. A chain from NewMarkVShaney(n) panics in use for n < 1
+// (Initial's make(Prefix, n) for n < 0, or Shift's slicing for
+// n == 0), which was fine when the
+// prefix length was a compile-time constant only a developer could get
+// wrong, but -prefix now takes it from the command line.
+func validatePrefixLen(n int) error {
+	if n < 1 {
+		return fmt.Errorf("mark: -prefix must be at least 1, got %d", n)
+	}
+	return nil
+}
+
+// loadState loads a previously saved chain from filename into mvs. A
+// missing file is not an error: it just means this is the first run
+// against that state file.
+func loadState(filename string, mvs *MarkVShaney) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+	return mvs.Load(f)
+}
+
+// saveState writes mvs to filename, overwriting any previous contents.
+func saveState(filename string, mvs *MarkVShaney) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return mvs.Save(f)
 }
 
 // parseInput parses in input file for paragraphs and then parses
-// those paragraphs with parseParagraph. Returns an error if the given
-// filename could not be opened.
-func parseInput(filename string, mvs MarkVShaney) error {
+// those paragraphs with parseParagraph, using tok to split each
+// paragraph into tokens. Returns an error if the given filename could
+// not be opened.
+func parseInput(filename string, mvs *MarkVShaney, tok Tokenizer) error {
 	f, err := os.Open(filename)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	return trainReader(f, mvs, tok)
+}
 
-	scanner := bufio.NewScanner(f)
+// trainReader parses r for paragraphs and feeds each one to mvs via
+// parseParagraph, the same way parseInput does for a named file. It is
+// also used to train a chain from an HTTP request body.
+func trainReader(r io.Reader, mvs *MarkVShaney, tok Tokenizer) error {
+	scanner := bufio.NewScanner(r)
 	scanner.Split(scanParagraph)
 	for scanner.Scan() {
-		parseParagraph(scanner.Text(), mvs)
+		parseParagraphWith(scanner.Text(), mvs, tok)
 	}
-	return nil
+	return scanner.Err()
 }
 
-// parseParagraph parses a single paragraph for words and feeds the words
-// and their prefixes into a MarkVShaney.
-func parseParagraph(p string, mvs MarkVShaney) {
-	prefix := Initial
-	scanner := bufio.NewScanner(strings.NewReader(p))
-	scanner.Split(bufio.ScanWords)
-	for scanner.Scan() {
-		word := scanner.Text()
-		mvs.Add(prefix, word)
-		prefix.Shift(word)
-	}
+// parseParagraph parses a single paragraph for words, using the
+// original whitespace-splitting wordTokenizer policy, and feeds the words
+// and their prefixes into mvs.
+func parseParagraph(p string, mvs *MarkVShaney) {
+	parseParagraphWith(p, mvs, wordTokenizer{})
+}
+
+// parseParagraphWith is parseParagraph, but splits p into tokens with
+// tok instead of always using wordTokenizer, so callers can select the
+// segmentation policy (see tokenize.go).
+func parseParagraphWith(p string, mvs *MarkVShaney, tok Tokenizer) {
+	mvs.AddTokens(tok.Tokenize(p))
 }
 
 // scanParagraph is a bufio.SplitFunc for a scanner to split input on paragraph
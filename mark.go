@@ -20,36 +20,135 @@
 // However, it is up to the caller to decide what the document boundary
 // is.
 //
-// The prefix length is a constant defined in this file. The longer the
-// prefix, the more the output document will resemble the input corpus,
-// following longer phrases of the corpus. The usual length for this is
-// two.
+// The prefix length is configurable per chain, via NewMarkVShaney. The
+// longer the prefix, the more the output document will resemble the
+// input corpus, following longer phrases of the corpus. The usual
+// length for this is two.
 
 package main
 
 import (
+	"fmt"
 	"math/rand"
+	"sort"
+	"strings"
+	"sync"
 )
 
-const PrefixLength = 2
+// WordBag is a frequency-weighted set of words: the words observed
+// following some Prefix, and how many times each was observed. Sampling
+// a weighted random word from it is done via a lazily-built sampler,
+// since the counts rarely change between samples. Its own mutex guards
+// that lazy build, since GetOneWith (unlike add) is only ever called
+// while MarkVShaney.mu is held for reading, so concurrent readers on
+// the same bag must still serialize with each other to build it once.
+type WordBag struct {
+	mu      sync.Mutex
+	counts  map[string]int
+	sampler *sampler
+}
 
-type WordBag map[string]int // frequency-weighted set of words
-type Prefix [PrefixLength]string
-type MarkVShaney map[Prefix]WordBag
+// newWordBag returns an empty WordBag.
+func newWordBag() *WordBag {
+	return &WordBag{counts: map[string]int{}}
+}
 
-var (
-	Initial  = Prefix{}
-	Terminal = "" // zero value, not a valid word in the input
-)
+// add records an observation of word, invalidating the cached sampler.
+func (bag *WordBag) add(word string) {
+	bag.mu.Lock()
+	defer bag.mu.Unlock()
+	bag.counts[word]++
+	bag.sampler = nil
+}
+
+// Prefix is a sequence of words used as the key into a MarkVShaney chain.
+// Its length is set per MarkVShaney, by NewMarkVShaney or by Load from
+// saved state, rather than baked into the type, so chains of different
+// prefix lengths can coexist in the same program.
+type Prefix []string
+
+// key returns the string used to index a Prefix into MarkVShaney.chain.
+// Joining on "\x00" keeps prefixes with different words from colliding
+// on the same key as long as no word contains a NUL byte; the
+// Tokenizers do not remove NUL bytes from their input.
+func (p Prefix) key() string {
+	return strings.Join(p, "\x00")
+}
+
+// Shift puts a new word at the end of a Prefix, shifting down the others.
+func (p Prefix) Shift(word string) {
+	copy(p, p[1:])
+	p[len(p)-1] = word
+}
+
+// MarkVShaney is a Markov Chain of words, mapping a Prefix to the
+// WordBag of words that have been observed following it. It is safe
+// for concurrent use by multiple goroutines, e.g. training and
+// generating at the same time from an HTTP handler.
+type MarkVShaney struct {
+	mu        sync.RWMutex
+	prefixLen int
+	chain     map[string]*WordBag
+}
+
+var Terminal = "" // zero value, not a valid word in the input
+
+// maxDocumentWords bounds how many words GetDocument and
+// GetDocumentFrom will walk before stopping. Training data that forms
+// a cycle with no prefix that ever resolves to Terminal - e.g. "A B A
+// B", where ["A","B"] and ["B","A"] each have exactly one known
+// successor - would otherwise make the walk spin forever; since the
+// GET /generate handler runs GetDocumentFrom, an unbounded walk is a
+// trivial way for a client to park a goroutine at 100% CPU forever.
+const maxDocumentWords = 1000
+
+// NewMarkVShaney returns a MarkVShaney chain whose prefixes are n words
+// long. The usual length is two.
+func NewMarkVShaney(n int) *MarkVShaney {
+	return &MarkVShaney{
+		prefixLen: n,
+		chain:     map[string]*WordBag{},
+	}
+}
+
+// Initial returns the empty Prefix used to start and reset a document,
+// sized for this chain's prefix length.
+func (mvs *MarkVShaney) Initial() Prefix {
+	mvs.mu.RLock()
+	defer mvs.mu.RUnlock()
+	return make(Prefix, mvs.prefixLen)
+}
 
 // Add a word to the Markov Chain for a given Prefix.
-func (mvs MarkVShaney) Add(prefix Prefix, word string) {
-	bag, ok := mvs[prefix]
+func (mvs *MarkVShaney) Add(prefix Prefix, word string) {
+	mvs.mu.Lock()
+	defer mvs.mu.Unlock()
+
+	key := prefix.key()
+	bag, ok := mvs.chain[key]
 	if !ok {
-		bag = WordBag{}
-		mvs[prefix] = bag
+		bag = newWordBag()
+		mvs.chain[key] = bag
+	}
+	bag.add(word)
+}
+
+// AddTokens feeds a pre-tokenized sequence of words into the chain,
+// starting from Initial and resetting back to Initial whenever a
+// Terminal token appears in tokens. This lets a Tokenizer that marks
+// sentence boundaries (see tokenize.go) reset the chain's prefix at
+// each sentence, the same way parseParagraph already resets it between
+// paragraphs.
+func (mvs *MarkVShaney) AddTokens(tokens []string) {
+	prefix := mvs.Initial()
+	for _, tok := range tokens {
+		if tok == Terminal {
+			prefix = mvs.Initial()
+			continue
+		}
+		mvs.Add(prefix, tok)
+		prefix.Shift(tok)
 	}
-	bag[word]++
 }
 
 // GetDocument returns a list of Words generated by walking the chain. A
@@ -58,10 +157,10 @@ func (mvs MarkVShaney) Add(prefix Prefix, word string) {
 // used, the document always starts at the same place. A paragraph of
 // text is the most common sort of document, but the document boundaries
 // are up to the caller of `MarkVShaney.Add`.
-func (mvs MarkVShaney) GetDocument() []string {
+func (mvs *MarkVShaney) GetDocument() []string {
 	result := []string{}
-	prefix := Initial
-	for {
+	prefix := mvs.Initial()
+	for len(result) < maxDocumentWords {
 		word := mvs.Walk(prefix)
 		if word == Terminal {
 			break
@@ -72,40 +171,127 @@ func (mvs MarkVShaney) GetDocument() []string {
 	return result
 }
 
+// GetDocumentFrom is GetDocument, but draws from rng instead of the
+// global rand source and starts the walk from a prefix resolved from
+// seed rather than always from Initial. seed is a prompt supplied by
+// the caller, e.g. the words typed after a "!talk" command.
+//
+// If seed is empty, generation starts from Initial, as GetDocument
+// does. Otherwise, mvs looks for a prefix exactly matching the last
+// words of seed; failing that, it tries progressively shorter suffixes
+// of seed; failing that, it picks at random among prefixes whose first
+// word matches the last word of seed. If none of those prefixes exist
+// in the chain, it returns an error rather than silently falling back
+// to Initial.
+func (mvs *MarkVShaney) GetDocumentFrom(seed []string, rng *rand.Rand) ([]string, error) {
+	prefix, err := mvs.resolveSeed(seed, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for len(result) < maxDocumentWords {
+		word := mvs.WalkWith(prefix, rng)
+		if word == Terminal {
+			break
+		}
+		result = append(result, word)
+		prefix.Shift(word)
+	}
+	return result, nil
+}
+
+// resolveSeed finds a Prefix in the chain to start generation from,
+// following the fallback rules documented on GetDocumentFrom.
+func (mvs *MarkVShaney) resolveSeed(seed []string, rng *rand.Rand) (Prefix, error) {
+	if len(seed) == 0 {
+		return mvs.Initial(), nil
+	}
+
+	mvs.mu.RLock()
+	defer mvs.mu.RUnlock()
+
+	for n := len(seed); n > 0; n-- {
+		if n > mvs.prefixLen {
+			continue
+		}
+		tail := seed[len(seed)-n:]
+		prefix := make(Prefix, mvs.prefixLen)
+		copy(prefix[mvs.prefixLen-n:], tail)
+		if _, ok := mvs.chain[prefix.key()]; ok {
+			return prefix, nil
+		}
+	}
+
+	last := seed[len(seed)-1]
+	var matches []Prefix
+	for key := range mvs.chain {
+		words := strings.Split(key, "\x00")
+		if len(words) == mvs.prefixLen && words[0] == last {
+			matches = append(matches, Prefix(words))
+		}
+	}
+	if len(matches) > 0 {
+		// Sort before indexing with rng: mvs.chain is a map, so ranging
+		// over it above collected matches in randomized order, and
+		// intn(rng, ...) must pick from a stable ordering for a given
+		// rng seed to reproducibly choose the same prefix.
+		sort.Slice(matches, func(i, j int) bool {
+			return matches[i].key() < matches[j].key()
+		})
+		return matches[intn(rng, len(matches))], nil
+	}
+
+	return nil, fmt.Errorf("mark: no prefix in chain matches seed %v", seed)
+}
+
+// intn returns a random number in [0, n) from rng, or from the global
+// rand source if rng is nil.
+func intn(rng *rand.Rand, n int) int {
+	if rng == nil {
+		return rand.Intn(n)
+	}
+	return rng.Intn(n)
+}
+
 // Walk returns a random word from the Markov Chain given a Prefix.
-func (mvs MarkVShaney) Walk(prefix Prefix) string {
-	if bag, ok := mvs[prefix]; ok {
+func (mvs *MarkVShaney) Walk(prefix Prefix) string {
+	mvs.mu.RLock()
+	defer mvs.mu.RUnlock()
+
+	if bag, ok := mvs.chain[prefix.key()]; ok {
 		return bag.GetOne()
 	}
 	return Terminal
 }
 
-// GetOne returns a random word from the WordBag, weighted by the word frequency.
-func (bag WordBag) GetOne() string {
-	entry := rand.Intn(bag.Len())
-	var sum int
-	for word, count := range bag {
-		sum += count
-		if entry <= sum {
-			return word
-		}
+// WalkWith is Walk, but draws from rng instead of the global rand
+// source, so callers can make generation deterministic.
+func (mvs *MarkVShaney) WalkWith(prefix Prefix, rng *rand.Rand) string {
+	mvs.mu.RLock()
+	defer mvs.mu.RUnlock()
+
+	if bag, ok := mvs.chain[prefix.key()]; ok {
+		return bag.GetOneWith(rng)
 	}
-	// Should never be reached, unless the bag length changes under us
-	panic("entry out of range")
+	return Terminal
 }
 
-// Len returns the length of the WordBag, summing all the weights.
-func (bag WordBag) Len() (sum int) {
-	for _, count := range bag {
-		sum += count
-	}
-	return
+// GetOne returns a random word from the WordBag, weighted by the word
+// frequency, in O(1) via the bag's alias-method sampler.
+func (bag *WordBag) GetOne() string {
+	return bag.GetOneWith(nil)
 }
 
-// Shift puts a new word at the end of a Prefix, shifting down the others.
-func (p *Prefix) Shift(word string) {
-	for i := 1; i < len(p); i++ {
-		p[i-1] = p[i]
+// GetOneWith is GetOne, but draws from rng instead of the global rand
+// source, so callers can make generation deterministic. A nil rng falls
+// back to the global rand source.
+func (bag *WordBag) GetOneWith(rng *rand.Rand) string {
+	bag.mu.Lock()
+	if bag.sampler == nil {
+		bag.sampler = newSampler(bag.counts)
 	}
-	p[len(p)-1] = word
+	s := bag.sampler
+	bag.mu.Unlock()
+	return s.sample(rng)
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestGetDocumentFromEmptySeed(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+	parseParagraph(persistCorpus, mvs)
+
+	rng := rand.New(rand.NewSource(1))
+	got, err := mvs.GetDocumentFrom(nil, rng)
+	if err != nil {
+		t.Fatalf("GetDocumentFrom(nil, ...): %v", err)
+	}
+	if len(got) == 0 {
+		t.Error("GetDocumentFrom(nil, ...) returned an empty document")
+	}
+}
+
+func TestGetDocumentFromExactSeed(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+	parseParagraph(persistCorpus, mvs)
+
+	rng := rand.New(rand.NewSource(1))
+	got, err := mvs.GetDocumentFrom([]string{"the", "quick"}, rng)
+	if err != nil {
+		t.Fatalf("GetDocumentFrom: %v", err)
+	}
+	if len(got) == 0 || got[0] != "brown" {
+		t.Errorf("GetDocumentFrom([the quick], ...) = %v, want it to start with %q", got, "brown")
+	}
+}
+
+func TestGetDocumentFromNoMatch(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+	parseParagraph(persistCorpus, mvs)
+
+	rng := rand.New(rand.NewSource(1))
+	if _, err := mvs.GetDocumentFrom([]string{"xyzzy"}, rng); err == nil {
+		t.Error("GetDocumentFrom with unmatched seed: got nil error, want non-nil")
+	}
+}
+
+// BenchmarkConcurrentTrainAndGenerate exercises Add and GetDocument from
+// many goroutines at once, the mixed read/write load a running HTTP
+// server puts on a chain.
+func BenchmarkConcurrentTrainAndGenerate(b *testing.B) {
+	mvs := NewMarkVShaney(2)
+	parseParagraph(persistCorpus, mvs)
+
+	words := strings.Fields(persistCorpus)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		prefix := mvs.Initial()
+		for pb.Next() {
+			if i%4 == 0 {
+				mvs.Add(prefix, words[i%len(words)])
+				prefix.Shift(words[i%len(words)])
+			} else {
+				mvs.GetDocument()
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Persistence for a MarkVShaney chain, so a chain built from a large
+// corpus can be saved and later reloaded and extended, rather than
+// reparsing the whole corpus on every run.
+
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// gobMagic identifies a MarkVShaney state stream and its encoding
+// version, so a future change to the WordBag or Prefix representation
+// can detect and migrate older state files instead of failing to decode
+// or, worse, silently misreading them. It was bumped to MVS2 when
+// WordBag grew a cached sampler, since gobChain's Chain field is kept
+// as plain word counts precisely so that change didn't need to touch
+// the on-disk format further.
+const gobMagic = "MVS2"
+
+// gobChain is the gob-encoded form of a MarkVShaney. Its Chain field
+// stores plain word counts rather than *WordBag, both because
+// MarkVShaney's and WordBag's fields are unexported, and so that
+// WordBag's cached sampler never needs to be (de)serialized.
+type gobChain struct {
+	PrefixLen int
+	Chain     map[string]map[string]int
+}
+
+// Save writes the chain to w as a gob stream, prefixed with a magic
+// header identifying the encoding version.
+func (mvs *MarkVShaney) Save(w io.Writer) error {
+	mvs.mu.RLock()
+	defer mvs.mu.RUnlock()
+
+	if _, err := io.WriteString(w, gobMagic); err != nil {
+		return err
+	}
+	chain := make(map[string]map[string]int, len(mvs.chain))
+	for key, bag := range mvs.chain {
+		chain[key] = bag.counts
+	}
+	g := gobChain{PrefixLen: mvs.prefixLen, Chain: chain}
+	return gob.NewEncoder(w).Encode(&g)
+}
+
+// Load replaces the chain's contents with the state read from r, as
+// written by Save. It returns an error if r does not start with the
+// expected magic header or if the gob stream cannot be decoded.
+func (mvs *MarkVShaney) Load(r io.Reader) error {
+	magic := make([]byte, len(gobMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return fmt.Errorf("mark: reading state header: %w", err)
+	}
+	if string(magic) != gobMagic {
+		return fmt.Errorf("mark: unrecognized state file (got header %q)", magic)
+	}
+
+	var g gobChain
+	if err := gob.NewDecoder(r).Decode(&g); err != nil {
+		return fmt.Errorf("mark: decoding state: %w", err)
+	}
+
+	chain := make(map[string]*WordBag, len(g.Chain))
+	for key, counts := range g.Chain {
+		chain[key] = &WordBag{counts: counts}
+	}
+
+	mvs.mu.Lock()
+	defer mvs.mu.Unlock()
+	mvs.prefixLen = g.PrefixLen
+	mvs.chain = chain
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// persistCorpus has no repeated word pair, so every prefix the chain
+// sees has exactly one entry in its WordBag: GetDocument always walks
+// the same straight line from "the" to "quietly" and falls off the end
+// into Terminal, regardless of sampling order. A corpus with a
+// repeated pair can turn into a cycle with no prefix that ever
+// resolves to Terminal, in which case GetDocument only stops at
+// maxDocumentWords. getDocumentWithTimeout below fails fast if
+// GetDocument ever hangs rather than hanging the whole test suite.
+const persistCorpus = "the quick brown fox jumps over the lazy sleeping dog while the swift cat runs past quietly"
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+	parseParagraph(persistCorpus, mvs)
+
+	var buf bytes.Buffer
+	if err := mvs.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded := NewMarkVShaney(0)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	rand.Seed(1)
+	want := getDocumentWithTimeout(t, mvs, time.Second)
+	rand.Seed(1)
+	got := getDocumentWithTimeout(t, loaded, time.Second)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("GetDocument after round-trip = %v, want %v", got, want)
+	}
+}
+
+// getDocumentWithTimeout runs mvs.GetDocument() in a goroutine and
+// fails t if it doesn't return within timeout, so a GetDocument that
+// hangs fails this test fast instead of wedging the whole suite.
+func getDocumentWithTimeout(t *testing.T, mvs *MarkVShaney, timeout time.Duration) []string {
+	t.Helper()
+	done := make(chan []string, 1)
+	go func() { done <- mvs.GetDocument() }()
+	select {
+	case doc := <-done:
+		return doc
+	case <-time.After(timeout):
+		t.Fatalf("GetDocument() did not return within %s", timeout)
+		return nil
+	}
+}
+
+func TestLoadBadMagic(t *testing.T) {
+	mvs := NewMarkVShaney(2)
+	if err := mvs.Load(bytes.NewBufferString("not a state file")); err == nil {
+		t.Error("Load with bad magic header: got nil error, want non-nil")
+	}
+}
@@ -0,0 +1,106 @@
+// sampler implements Vose's alias method for drawing weighted random
+// samples in O(1), replacing the O(n) "walk the cumulative weights"
+// scan that WordBag.GetOne used to do on every call (and which, because
+// Go randomizes map iteration order, didn't even walk the weights in a
+// consistent order from one run to the next).
+
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// sampler draws a weighted random index in O(1) using two parallel
+// tables of length n, built in O(n) by partitioning entries into
+// "small" and "large" stacks around the average weight 1/n.
+type sampler struct {
+	words []string
+	prob  []float64
+	alias []int
+}
+
+// newSampler builds a sampler over counts, a set of words and their
+// (positive) integer weights. words is sorted before the alias tables
+// are built, so which word ends up at a given index - and hence which
+// word a given rng draw returns - doesn't depend on Go's randomized
+// map iteration order; without that, the same rng seed over two
+// *different* map objects holding identical counts (e.g. before and
+// after a save/load round trip) could sample different words.
+func newSampler(counts map[string]int) *sampler {
+	n := len(counts)
+	words := make([]string, 0, n)
+	for word := range counts {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	scaled := make([]float64, n)
+	for i, word := range words {
+		scaled[i] = float64(counts[word]) * float64(n) / float64(total)
+	}
+
+	prob := make([]float64, n)
+	alias := make([]int, n)
+
+	small := make([]int, 0, n)
+	large := make([]int, 0, n)
+	for i, w := range scaled {
+		if w < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		prob[s] = scaled[s]
+		alias[s] = l
+
+		scaled[l] = scaled[l] + scaled[s] - 1
+		if scaled[l] < 1 {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+	// Leftover entries in either stack are only here due to floating
+	// point error accumulating around the 1.0 boundary; treat them as
+	// certain (prob 1, no alias needed).
+	for _, i := range large {
+		prob[i] = 1
+	}
+	for _, i := range small {
+		prob[i] = 1
+	}
+
+	return &sampler{words: words, prob: prob, alias: alias}
+}
+
+// sample draws a word in O(1), weighted by the counts the sampler was
+// built from, using rng. A nil rng falls back to the global rand source.
+func (s *sampler) sample(rng *rand.Rand) string {
+	i := intn(rng, len(s.words))
+	if floatn(rng) < s.prob[i] {
+		return s.words[i]
+	}
+	return s.words[s.alias[i]]
+}
+
+// floatn returns a random number in [0.0, 1.0) from rng, or from the
+// global rand source if rng is nil.
+func floatn(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
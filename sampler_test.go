@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func largeWordBagCounts(n int) map[string]int {
+	counts := make(map[string]int, n)
+	for i := 0; i < n; i++ {
+		counts[fmt.Sprintf("word%d", i)] = i%50 + 1
+	}
+	return counts
+}
+
+func TestSamplerOnlyReturnsKnownWords(t *testing.T) {
+	counts := map[string]int{"a": 1, "b": 5, "c": 2}
+	s := newSampler(counts)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		word := s.sample(rng)
+		if _, ok := counts[word]; !ok {
+			t.Fatalf("sample() = %q, not one of the weighted words", word)
+		}
+	}
+}
+
+func BenchmarkGetOneAliasMethod(b *testing.B) {
+	bag := &WordBag{counts: largeWordBagCounts(10000)}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bag.GetOne()
+	}
+}
+
+// BenchmarkGetOneLinearScan reproduces the O(n) map-walk WordBag.GetOne
+// used before it was backed by an alias-method sampler, as a baseline
+// to compare against.
+func BenchmarkGetOneLinearScan(b *testing.B) {
+	counts := largeWordBagCounts(10000)
+	var total int
+	for _, c := range counts {
+		total += c
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entry := rand.Intn(total)
+		var sum int
+		for _, count := range counts {
+			sum += count
+			if entry <= sum {
+				break
+			}
+		}
+	}
+}
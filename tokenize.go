@@ -0,0 +1,114 @@
+// Tokenizer policies for turning a paragraph of input text into the
+// words fed into a MarkVShaney chain. The original policy, splitting
+// on whitespace via bufio.ScanWords, treats "word" and "word," as
+// distinct tokens; the unicode-aware policies below split trailing
+// punctuation off into tokens of its own, at the cost of a little more
+// work per paragraph.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer splits a paragraph of text into the tokens AddTokens feeds
+// into a MarkVShaney chain.
+type Tokenizer interface {
+	Tokenize(paragraph string) []string
+}
+
+// tokenizerByName resolves the -tokenize flag to a Tokenizer, or
+// returns an error naming the valid choices.
+func tokenizerByName(name string) (Tokenizer, error) {
+	switch name {
+	case "words":
+		return wordTokenizer{}, nil
+	case "unicode":
+		return unicodeTokenizer{}, nil
+	case "sentences":
+		return sentenceTokenizer{}, nil
+	default:
+		return nil, fmt.Errorf("mark: unknown -tokenize value %q (want words, unicode, or sentences)", name)
+	}
+}
+
+// wordTokenizer is the original policy: split on Unicode whitespace via
+// bufio.ScanWords, leaving attached punctuation as part of the word.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Tokenize(paragraph string) []string {
+	var tokens []string
+	scanner := bufio.NewScanner(strings.NewReader(paragraph))
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	return tokens
+}
+
+// sentenceEnders are the punctuation runes sentenceTokenizer treats as
+// closing a sentence.
+const sentenceEnders = ".!?"
+
+// unicodeTokenizer segments on Unicode whitespace (unicode.IsSpace),
+// and splits the trailing run of punctuation off of each word
+// into its own tokens, so "Shaney." trains the chain on "Shaney" and
+// "." separately instead of bloating it with a one-off "Shaney." entry.
+type unicodeTokenizer struct {
+	Lowercase bool
+}
+
+func (t unicodeTokenizer) Tokenize(paragraph string) []string {
+	var tokens []string
+	for _, field := range strings.FieldsFunc(paragraph, unicode.IsSpace) {
+		word, punct := splitTrailingPunct(field)
+		if word != "" {
+			if t.Lowercase {
+				word = strings.ToLower(word)
+			}
+			tokens = append(tokens, word)
+		}
+		for _, r := range punct {
+			tokens = append(tokens, string(r))
+		}
+	}
+	return tokens
+}
+
+// splitTrailingPunct splits the trailing run of punctuation off of
+// field, e.g. splitTrailingPunct("Shaney.") == ("Shaney", ".").
+func splitTrailingPunct(field string) (word, punct string) {
+	end := len(field)
+	for end > 0 {
+		r, size := utf8.DecodeLastRuneInString(field[:end])
+		if !unicode.IsPunct(r) {
+			break
+		}
+		end -= size
+	}
+	return field[:end], field[end:]
+}
+
+// sentenceTokenizer is unicodeTokenizer, but emits Terminal after every
+// sentence-ending punctuation mark, so MarkVShaney.AddTokens resets the
+// chain's prefix at each sentence boundary rather than only at
+// paragraph boundaries.
+type sentenceTokenizer struct {
+	Lowercase bool
+}
+
+func (t sentenceTokenizer) Tokenize(paragraph string) []string {
+	tokens := unicodeTokenizer{Lowercase: t.Lowercase}.Tokenize(paragraph)
+	out := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		out = append(out, tok)
+		if len(tok) == 1 && strings.ContainsRune(sentenceEnders, rune(tok[0])) {
+			out = append(out, Terminal)
+		}
+	}
+	return out
+}
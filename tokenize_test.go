@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnicodeTokenizerSplitsTrailingPunctuation(t *testing.T) {
+	got := unicodeTokenizer{}.Tokenize("Mark V. Shaney said hello, world!")
+	want := []string{"Mark", "V", ".", "Shaney", "said", "hello", ",", "world", "!"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestUnicodeTokenizerSplitsUnicodeWhitespace(t *testing.T) {
+	got := unicodeTokenizer{}.Tokenize("one two three")
+	want := []string{"one", "two", "three"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestUnicodeTokenizerLowercase(t *testing.T) {
+	got := unicodeTokenizer{Lowercase: true}.Tokenize("Mark Shaney")
+	want := []string{"mark", "shaney"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestSentenceTokenizerEmitsTerminalAtSentenceEnd(t *testing.T) {
+	got := sentenceTokenizer{}.Tokenize("One fish. Two fish!")
+	want := []string{"One", "fish", ".", Terminal, "Two", "fish", "!", Terminal}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestTokenizerByNameUnknown(t *testing.T) {
+	if _, err := tokenizerByName("paragraphs"); err == nil {
+		t.Error("tokenizerByName(\"paragraphs\"): got nil error, want non-nil")
+	}
+}
+
+func TestAddTokensResetsOnTerminal(t *testing.T) {
+	mvs := NewMarkVShaney(1)
+	mvs.AddTokens([]string{"a", "b", Terminal, "c", "d"})
+
+	prefix := Prefix{"a"}
+	if got := mvs.Walk(prefix); got != "b" {
+		t.Errorf("Walk([a]) = %q, want %q", got, "b")
+	}
+	prefix = Prefix{"b"}
+	if got := mvs.Walk(prefix); got != Terminal {
+		t.Errorf("Walk([b]) = %q, want Terminal: the Terminal token should have reset the prefix", got)
+	}
+}